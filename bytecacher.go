@@ -2,36 +2,72 @@ package bytecacher
 
 import (
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
-	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Cacher struct {
 	Debug bool
 
-	lookup func(string) ([]byte, error)
-	path   string
+	// VerifyIntegrity, if set, checks each read against a SHA-256 trailer
+	// written at store time and treats a mismatch as a cache miss.
+	VerifyIntegrity bool
+
+	// MaxCapacityBytes and MaxItems bound the cache when set via
+	// NewCacherWithCapacity; zero means unbounded for that dimension.
+	MaxCapacityBytes int64
+	MaxItems         int
+
+	lookup       func(string) ([]byte, error)
+	streamLookup func(string) (io.ReadCloser, error)
+	path         string // root path for the streaming (GetReader) code path
+	storage      Storage
 
 	locksLock sync.RWMutex
 	locks     map[string]*sync.RWMutex
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+
+	heapLock   sync.Mutex
+	entries    map[string]*accessEntry
+	accessHeap *accessHeap
+	gcStop     chan struct{}
+	gcDone     chan struct{}
 }
 
+// NewCacher builds a Cacher backed by FSStorage rooted at path.
 func NewCacher(
 	lookupFn func(string) ([]byte, error),
 	path string,
 ) *Cacher {
-	cacher := &Cacher{
-		lookup: lookupFn,
-		path:   path,
+	c := NewCacherWithStorage(lookupFn, NewFSStorage(path))
+	c.path = path
+	return c
+}
+
+// NewCacherWithStorage builds a Cacher over an arbitrary Storage backend,
+// layering the usual locking/maxAge/singleflight logic over it so callers
+// can plug in anything that implements Storage (FSStorage, InMemoryStorage,
+// or a custom blob-store backend) instead of being tied to the local
+// filesystem. GetReader still talks to the filesystem directly and needs
+// the streaming lookup that only NewStreamingCacher sets, so it is only
+// meaningful on a Cacher built with NewStreamingCacher.
+func NewCacherWithStorage(
+	lookupFn func(string) ([]byte, error),
+	storage Storage,
+) *Cacher {
+	return &Cacher{
+		lookup:  lookupFn,
+		storage: storage,
 
 		locksLock: sync.RWMutex{},
 		locks:     map[string]*sync.RWMutex{},
 	}
-
-	return cacher
 }
 
 func (c *Cacher) debug(fmt string, rest ...interface{}) {
@@ -68,20 +104,26 @@ func (c *Cacher) Get(key string) ([]byte, error) {
 	c.debug("checking for %s in cache", key)
 	if ans, _, err := c.retrieve(key); err == nil {
 		c.debug("found!")
+		atomic.AddUint64(&c.hits, 1)
+		c.touch(key, int64(len(ans)))
 		return ans, nil
 	}
 
-	return c.store(key)
+	atomic.AddUint64(&c.misses, 1)
+	return c.store(key, 0)
 }
 
 func (c *Cacher) GetMaxAge(key string, maxAge time.Duration) ([]byte, error) {
 	c.debug("checking for %s in cache", key)
 	if ans, mtime, err := c.retrieve(key); err == nil && time.Since(mtime) < maxAge {
 		c.debug("found!")
+		atomic.AddUint64(&c.hits, 1)
+		c.touch(key, int64(len(ans)))
 		return ans, nil
 	}
 
-	return c.store(key)
+	atomic.AddUint64(&c.misses, 1)
+	return c.store(key, maxAge)
 }
 
 func (c *Cacher) retrieve(key string) ([]byte, time.Time, error) {
@@ -89,61 +131,65 @@ func (c *Cacher) retrieve(key string) ([]byte, time.Time, error) {
 	mx.RLock()
 	defer mx.RUnlock()
 
-	path := c.filePath(key)
-
-	exists, mtime := fileExists(path)
-	if !exists {
-		return []byte{}, time.Time{}, fmt.Errorf("not stored")
-	}
+	return c.retrieveLocked(key)
+}
 
-	dat, err := ioutil.ReadFile(path)
+// retrieveLocked is retrieve without taking getMx(key) itself, for callers
+// that already hold it (store uses this to coalesce concurrent misses).
+func (c *Cacher) retrieveLocked(key string) ([]byte, time.Time, error) {
+	dat, mtime, err := c.storage.Get(key)
 	if err != nil {
 		return []byte{}, time.Time{}, err
 	}
 
+	if c.VerifyIntegrity {
+		payload, ok := splitSumTrailer(dat)
+		if !ok {
+			c.debug("integrity check failed for %s, treating as miss", key)
+			return []byte{}, time.Time{}, fmt.Errorf("integrity check failed")
+		}
+		dat = payload
+	}
+
 	return dat, mtime, nil
 }
 
-func (c *Cacher) store(key string) ([]byte, error) {
+// store fetches key from the upstream lookup function and writes it to
+// storage. maxAge of zero means any previously-stored value is acceptable.
+//
+// store takes getMx(key) for its writer, so concurrent misses on the same
+// key serialize here; before calling the (possibly expensive) lookup
+// function, it re-checks the cache in case another goroutine already
+// populated this entry while this caller was waiting on the lock, so a
+// thundering herd of misses coalesces into a single upstream lookup.
+func (c *Cacher) store(key string, maxAge time.Duration) ([]byte, error) {
 	c.debug("cache miss")
 	mx := c.getMx(key)
 	mx.Lock()
 	defer mx.Unlock()
 
+	if ans, mtime, err := c.retrieveLocked(key); err == nil && (maxAge <= 0 || time.Since(mtime) < maxAge) {
+		c.debug("coalesced with in-flight store for %s", key)
+		atomic.AddUint64(&c.hits, 1)
+		c.touch(key, int64(len(ans)))
+		return ans, nil
+	}
+
 	ans, err := c.lookup(key)
 	if err != nil {
 		return []byte{}, err
 	}
 
-	path := c.filePath(key)
-	err = ioutil.WriteFile(path, ans, 0644)
-
-	return ans, err
-}
-
-func (c *Cacher) filePath(key string) string {
-	return c.path + "/" + key
-}
-
-// fileExists reports if there is a file at the given path or not.
-func fileExists(filePath string) (bool, time.Time) {
-
-	info, err := os.Stat(filePath)
-
-	if os.IsNotExist(err) {
-		return false, time.Time{}
+	payload := ans
+	if c.VerifyIntegrity {
+		payload = appendSumTrailer(ans)
 	}
 
-	if err == nil {
-		if info.IsDir() {
-			return false, time.Time{}
-		}
-
-		return true, info.ModTime()
+	if err := c.storage.Put(key, payload); err != nil {
+		return ans, err
 	}
 
-	log.Fatalf("encountered unhandled error %+v while statting file %s", err, filePath)
+	c.touch(key, int64(len(ans)))
 
-	// never happens
-	return false, time.Time{}
+	return ans, nil
 }
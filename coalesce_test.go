@@ -0,0 +1,92 @@
+package bytecacher
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConcurrentGetMissesCoalesceToOneLookup(t *testing.T) {
+	dir := t.TempDir()
+
+	var calls int64
+	release := make(chan struct{})
+	c := NewCacher(func(key string) ([]byte, error) {
+		atomic.AddInt64(&calls, 1)
+		<-release
+		return []byte("value"), nil
+	}, dir)
+
+	const n = 5
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			ans, err := c.Get("k")
+			if err != nil {
+				t.Errorf("Get: %v", err)
+				return
+			}
+			if string(ans) != "value" {
+				t.Errorf("Get = %q, want %q", ans, "value")
+			}
+		}()
+	}
+
+	// Give every goroutine a chance to reach the lookup call (or block on
+	// getMx waiting for it) before letting the one in-flight lookup finish.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("lookup calls = %d, want 1 for %d concurrent misses on the same key", calls, n)
+	}
+}
+
+func TestConcurrentGetReaderMissesCoalesceToOneStreamLookup(t *testing.T) {
+	dir := t.TempDir()
+
+	var calls int64
+	release := make(chan struct{})
+	c := NewStreamingCacher(func(key string) (io.ReadCloser, error) {
+		atomic.AddInt64(&calls, 1)
+		<-release
+		return io.NopCloser(strings.NewReader("value")), nil
+	}, dir)
+
+	const n = 5
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			r, err := c.GetReader("k")
+			if err != nil {
+				t.Errorf("GetReader: %v", err)
+				return
+			}
+			ans, err := io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				t.Errorf("reading: %v", err)
+				return
+			}
+			if string(ans) != "value" {
+				t.Errorf("GetReader contents = %q, want %q", ans, "value")
+			}
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("streamLookup calls = %d, want 1 for %d concurrent misses on the same key", calls, n)
+	}
+}
@@ -0,0 +1,59 @@
+package bytecacher
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const tmpFilePrefix = ".tmp-"
+
+const sumTrailerLen = sha256.Size
+
+// appendSumTrailer appends a fixed-length SHA-256 trailer of data to
+// itself, so a later read can detect a corrupted or truncated payload
+// regardless of which Storage backend is in play.
+func appendSumTrailer(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return append(append([]byte{}, data...), sum[:]...)
+}
+
+// splitSumTrailer validates and strips a trailer written by
+// appendSumTrailer, returning ok=false if it is missing or doesn't match.
+func splitSumTrailer(data []byte) (payload []byte, ok bool) {
+	if len(data) < sumTrailerLen {
+		return nil, false
+	}
+
+	payload = data[:len(data)-sumTrailerLen]
+	trailer := data[len(data)-sumTrailerLen:]
+
+	sum := sha256.Sum256(payload)
+	return payload, bytes.Equal(trailer, sum[:])
+}
+
+// sweepTempFiles removes stray temp files left behind by a process that
+// crashed mid-write in a previous run.
+func sweepTempFiles(path string) error {
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		if info.IsDir() || !strings.HasPrefix(info.Name(), tmpFilePrefix) {
+			return nil
+		}
+
+		return os.Remove(p)
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
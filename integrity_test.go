@@ -0,0 +1,116 @@
+package bytecacher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIntegrityCheckRefetchesCorruptedEntry(t *testing.T) {
+	dir := t.TempDir()
+
+	var calls int
+	c := NewCacher(func(key string) ([]byte, error) {
+		calls++
+		return []byte(fmt.Sprintf("val-%d", calls)), nil
+	}, dir)
+	c.VerifyIntegrity = true
+
+	first, err := c.Get("k")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("calls after first Get = %d, want 1", calls)
+	}
+
+	path := shardedPath(dir, "k")
+	if err := os.WriteFile(path, []byte("corrupted"), 0644); err != nil {
+		t.Fatalf("corrupting entry: %v", err)
+	}
+
+	second, err := c.Get("k")
+	if err != nil {
+		t.Fatalf("Get after corruption: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("calls after corruption = %d, want 2 (corrupted entry should be a miss)", calls)
+	}
+	if string(second) == "corrupted" || string(second) == string(first) {
+		t.Errorf("Get after corruption = %q, want a freshly re-fetched value", second)
+	}
+}
+
+func TestStoreIsAtomicNoTempFileLeftBehindOnSuccess(t *testing.T) {
+	dir := t.TempDir()
+
+	c := NewCacher(func(key string) ([]byte, error) {
+		return []byte("v"), nil
+	}, dir)
+
+	if _, err := c.Get("k"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	assertNoTempFiles(t, dir)
+}
+
+func TestSweepTempFilesRemovesStrayTempFilesOnStartup(t *testing.T) {
+	dir := t.TempDir()
+
+	shardDir := filepath.Join(dir, "ab", "cd")
+	if err := os.MkdirAll(shardDir, 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	strayPath := filepath.Join(shardDir, tmpFilePrefix+"stray")
+	if err := os.WriteFile(strayPath, []byte("leftover"), 0644); err != nil {
+		t.Fatalf("seeding stray temp file: %v", err)
+	}
+
+	NewCacher(func(key string) ([]byte, error) { return nil, nil }, dir)
+
+	if _, err := os.Stat(strayPath); !os.IsNotExist(err) {
+		t.Errorf("stray temp file %s was not swept on startup", strayPath)
+	}
+}
+
+func TestSumTrailerRoundTrip(t *testing.T) {
+	data := []byte("hello world")
+
+	withTrailer := appendSumTrailer(data)
+	payload, ok := splitSumTrailer(withTrailer)
+	if !ok {
+		t.Fatal("splitSumTrailer reported mismatch on untouched data")
+	}
+	if string(payload) != string(data) {
+		t.Errorf("payload = %q, want %q", payload, data)
+	}
+
+	withTrailer[0] ^= 0xff
+	if _, ok := splitSumTrailer(withTrailer); ok {
+		t.Error("splitSumTrailer reported a match after corrupting the payload")
+	}
+
+	if _, ok := splitSumTrailer([]byte("short")); ok {
+		t.Error("splitSumTrailer reported a match for data shorter than a trailer")
+	}
+}
+
+func assertNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.HasPrefix(info.Name(), tmpFilePrefix) {
+			t.Errorf("stray temp file left behind: %s", p)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walking %s: %v", dir, err)
+	}
+}
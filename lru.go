@@ -0,0 +1,275 @@
+package bytecacher
+
+import (
+	"container/heap"
+	"sync/atomic"
+	"time"
+)
+
+// Stats holds running counters for a Cacher's hit/miss/eviction behaviour.
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// accessEntry tracks one on-disk cache file for LRU accounting.
+type accessEntry struct {
+	key   string
+	size  int64
+	atime time.Time
+	index int
+}
+
+// accessHeap is a min-heap of accessEntry ordered by atime, so the oldest
+// (least-recently-used) entry is always at the root.
+type accessHeap []*accessEntry
+
+func (h accessHeap) Len() int           { return len(h) }
+func (h accessHeap) Less(i, j int) bool { return h[i].atime.Before(h[j].atime) }
+func (h accessHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *accessHeap) Push(x interface{}) {
+	e := x.(*accessEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *accessHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	e.index = -1
+	*h = old[:n-1]
+	return e
+}
+
+// NewCacherWithCapacity builds a Cacher like NewCacher, but bounds the cache
+// to maxCapacityBytes total size and/or maxItems entries (zero means
+// unbounded for that dimension). Existing files under path are walked at
+// startup to seed the LRU heap so the cache survives restarts, and a
+// background goroutine evicts the least-recently-used entries whenever the
+// budget is exceeded. Callers must call Close when done to stop the
+// goroutine.
+func NewCacherWithCapacity(
+	lookupFn func(string) ([]byte, error),
+	path string,
+	maxCapacityBytes int64,
+	maxItems int,
+) (*Cacher, error) {
+	c := NewCacher(lookupFn, path)
+	c.MaxCapacityBytes = maxCapacityBytes
+	c.MaxItems = maxItems
+	c.entries = map[string]*accessEntry{}
+	c.accessHeap = &accessHeap{}
+	c.gcStop = make(chan struct{})
+	c.gcDone = make(chan struct{})
+
+	if err := c.rebuildHeap(); err != nil {
+		return nil, err
+	}
+
+	go c.gcLoop()
+
+	return c, nil
+}
+
+// rebuildHeap walks c.storage and seeds the LRU heap from the entries
+// already stored, so capacity accounting survives a restart.
+func (c *Cacher) rebuildHeap() error {
+	c.heapLock.Lock()
+	defer c.heapLock.Unlock()
+
+	return c.storage.Walk(func(key string, size int64, mtime time.Time) {
+		// size is the raw on-disk size; touch() on the live path records
+		// len(ans) after the integrity trailer (if any) has been
+		// stripped, so subtract it here when VerifyIntegrity is already
+		// set. NewCacherWithCapacity runs this before its caller can set
+		// VerifyIntegrity, so there the trailer is not subtracted: such
+		// entries count at their raw on-disk size until touch() records
+		// their payload size on the next access.
+		if c.VerifyIntegrity {
+			size -= sumTrailerLen
+			if size < 0 {
+				size = 0
+			}
+		}
+
+		e := &accessEntry{key: key, size: size, atime: mtime}
+		c.entries[key] = e
+		heap.Push(c.accessHeap, e)
+	})
+}
+
+// touch records an access for key, updating its position in the LRU heap.
+// This is in-memory only: Storage has no notion of "touch", so a restart
+// rebuilds atime from each entry's last write, not its last read.
+func (c *Cacher) touch(key string, size int64) {
+	if c.accessHeap == nil {
+		return
+	}
+
+	now := time.Now()
+
+	c.heapLock.Lock()
+	defer c.heapLock.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		e = &accessEntry{key: key, size: size, atime: now}
+		c.entries[key] = e
+		heap.Push(c.accessHeap, e)
+		return
+	}
+
+	e.size = size
+	e.atime = now
+	heap.Fix(c.accessHeap, e.index)
+}
+
+// gcLoop periodically evicts least-recently-used entries until the cache is
+// back within its configured budget.
+func (c *Cacher) gcLoop() {
+	defer close(c.gcDone)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.gcStop:
+			return
+		case <-ticker.C:
+			c.evictOverBudget()
+		}
+	}
+}
+
+// evictOverBudget deletes least-recently-used entries until the cache is
+// back within budget. Deleting a key's storage entry takes that key's
+// getMx lock, the same lock store() and GetReader hold for their whole
+// read-modify-write, so eviction can't land between a writer's lookup and
+// its touch() and delete a file out from under a write that's still in
+// flight; see popIfStillVictim for how the eviction decision itself is
+// re-checked once that lock is held.
+func (c *Cacher) evictOverBudget() {
+	for {
+		key, ok := c.peekVictimKey()
+		if !ok {
+			return
+		}
+
+		mx := c.getMx(key)
+		mx.Lock()
+
+		victim := c.popIfStillVictim(key)
+		if victim == nil {
+			// A concurrent store/touch changed this key's state (or
+			// someone else already evicted it) while we waited for the
+			// lock; re-evaluate from scratch instead of acting on stale
+			// bookkeeping.
+			mx.Unlock()
+			continue
+		}
+
+		err := c.storage.Delete(key)
+		mx.Unlock()
+
+		if err != nil {
+			// Put the entry back so it's still tracked (and eligible for
+			// eviction again on the next tick) instead of silently
+			// dropping it from bookkeeping while it's still on disk.
+			c.debug("eviction of %s failed: %v, will retry next cycle", key, err)
+			c.reinsertEntry(victim)
+			return
+		}
+
+		atomic.AddUint64(&c.evictions, 1)
+	}
+}
+
+// peekVictimKey returns the least-recently-used key without removing it
+// from the heap, if the cache is currently over its configured budget, so
+// the caller can acquire that key's per-key lock before committing to the
+// eviction.
+func (c *Cacher) peekVictimKey() (string, bool) {
+	c.heapLock.Lock()
+	defer c.heapLock.Unlock()
+
+	if !c.overBudgetLocked() {
+		return "", false
+	}
+
+	return (*c.accessHeap)[0].key, true
+}
+
+// popIfStillVictim removes and returns key's entry if the cache is still
+// over budget and key is still the least-recently-used entry. It must be
+// called with key's getMx lock held, and re-checks both conditions (rather
+// than trusting the earlier peekVictimKey) in case a store/touch for key
+// ran while the caller was waiting on that lock.
+func (c *Cacher) popIfStillVictim(key string) *accessEntry {
+	c.heapLock.Lock()
+	defer c.heapLock.Unlock()
+
+	if !c.overBudgetLocked() || (*c.accessHeap)[0].key != key {
+		return nil
+	}
+
+	e := heap.Pop(c.accessHeap).(*accessEntry)
+	delete(c.entries, e.key)
+	return e
+}
+
+// overBudgetLocked reports whether the cache is over its configured
+// budget. c.heapLock must already be held by the caller.
+func (c *Cacher) overBudgetLocked() bool {
+	if c.accessHeap == nil || c.accessHeap.Len() == 0 {
+		return false
+	}
+
+	var totalBytes int64
+	for _, e := range *c.accessHeap {
+		totalBytes += e.size
+	}
+
+	overCapacity := c.MaxCapacityBytes > 0 && totalBytes > c.MaxCapacityBytes
+	overItems := c.MaxItems > 0 && c.accessHeap.Len() > c.MaxItems
+	return overCapacity || overItems
+}
+
+// reinsertEntry puts e back into the LRU heap, for when an eviction
+// attempt fails after having already popped it.
+func (c *Cacher) reinsertEntry(e *accessEntry) {
+	c.heapLock.Lock()
+	defer c.heapLock.Unlock()
+
+	c.entries[e.key] = e
+	heap.Push(c.accessHeap, e)
+}
+
+// Stats returns a snapshot of the cache's hit/miss/eviction counters.
+func (c *Cacher) Stats() Stats {
+	return Stats{
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Evictions: atomic.LoadUint64(&c.evictions),
+	}
+}
+
+// Close stops the background GC goroutine started by NewCacherWithCapacity.
+// It is a no-op for a Cacher built with plain NewCacher.
+func (c *Cacher) Close() error {
+	if c.gcStop == nil {
+		return nil
+	}
+
+	close(c.gcStop)
+	<-c.gcDone
+	return nil
+}
@@ -0,0 +1,154 @@
+package bytecacher
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCapacityEvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+
+	c, err := NewCacherWithCapacity(func(key string) ([]byte, error) {
+		return []byte(strings.Repeat("x", 100)), nil
+	}, dir, 250, 0)
+	if err != nil {
+		t.Fatalf("NewCacherWithCapacity: %v", err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 5; i++ {
+		if _, err := c.Get(fmt.Sprintf("key%d", i)); err != nil {
+			t.Fatalf("Get(key%d): %v", i, err)
+		}
+	}
+
+	// Budget of 250 bytes at 100 bytes/entry should settle at 2 entries,
+	// evicting the other 3. gcLoop only evicts on its one-second tick, so
+	// wait for longer than one tick.
+	waitForCondition(t, 3*time.Second, func() bool {
+		return c.Stats().Evictions >= 3
+	})
+
+	stats := c.Stats()
+	if stats.Misses != 5 {
+		t.Errorf("misses = %d, want 5", stats.Misses)
+	}
+	if stats.Evictions != 3 {
+		t.Errorf("evictions = %d, want 3", stats.Evictions)
+	}
+
+	// key0..key2 were used least recently, so they are the ones evicted.
+	c.heapLock.Lock()
+	defer c.heapLock.Unlock()
+	for i := 0; i < 5; i++ {
+		_, kept := c.entries[fmt.Sprintf("key%d", i)]
+		if want := i >= 3; kept != want {
+			t.Errorf("key%d kept = %v, want %v", i, kept, want)
+		}
+	}
+}
+
+func TestMaxItemsEvictsDownToLimit(t *testing.T) {
+	dir := t.TempDir()
+
+	c, err := NewCacherWithCapacity(func(key string) ([]byte, error) {
+		return []byte("v"), nil
+	}, dir, 0, 2)
+	if err != nil {
+		t.Fatalf("NewCacherWithCapacity: %v", err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 4; i++ {
+		if _, err := c.Get(fmt.Sprintf("key%d", i)); err != nil {
+			t.Fatalf("Get(key%d): %v", i, err)
+		}
+	}
+
+	waitForCondition(t, 3*time.Second, func() bool {
+		return c.Stats().Evictions >= 2
+	})
+}
+
+func TestCloseStopsGC(t *testing.T) {
+	dir := t.TempDir()
+
+	c, err := NewCacherWithCapacity(func(key string) ([]byte, error) {
+		return []byte("v"), nil
+	}, dir, 1<<20, 0)
+	if err != nil {
+		t.Fatalf("NewCacherWithCapacity: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		c.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close did not return; GC goroutine likely still running")
+	}
+}
+
+func TestRebuildHeapSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	c1, err := NewCacherWithCapacity(func(key string) ([]byte, error) {
+		return []byte(strings.Repeat("y", 50)), nil
+	}, dir, 1<<20, 0)
+	if err != nil {
+		t.Fatalf("NewCacherWithCapacity: %v", err)
+	}
+	if _, err := c1.Get("persisted"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	c1.Close()
+
+	c2, err := NewCacherWithCapacity(func(key string) ([]byte, error) {
+		t.Fatalf("unexpected lookup for %s; entry should have survived restart", key)
+		return nil, nil
+	}, dir, 1<<20, 0)
+	if err != nil {
+		t.Fatalf("NewCacherWithCapacity: %v", err)
+	}
+	defer c2.Close()
+
+	c2.heapLock.Lock()
+	e, ok := c2.entries["persisted"]
+	c2.heapLock.Unlock()
+	if !ok {
+		t.Fatal("rebuilt heap has no entry for \"persisted\" after restart")
+	}
+	if e.size != 50 {
+		t.Errorf("rebuilt heap size for \"persisted\" = %d, want 50", e.size)
+	}
+
+	ans, err := c2.Get("persisted")
+	if err != nil {
+		t.Fatalf("Get after restart: %v", err)
+	}
+	if string(ans) != strings.Repeat("y", 50) {
+		t.Errorf("Get after restart = %q, want %q", ans, strings.Repeat("y", 50))
+	}
+}
+
+func waitForCondition(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if !cond() {
+		t.Fatalf("condition not met within %s", timeout)
+	}
+}
@@ -0,0 +1,66 @@
+package bytecacher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+)
+
+const keySidecarSuffix = ".key"
+
+// hashKey returns the hex-encoded SHA-256 digest of key, used to derive a
+// collision-resistant on-disk name for arbitrary keys.
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// shardedPath returns the sharded on-disk path for key under base: the key
+// is hashed and split into nested subdirectories (aa/bb/<hash>),
+// Git-object-style, so a single directory never ends up holding more than a
+// few thousand entries and keys containing "/" or other unsafe characters
+// are handled safely.
+func shardedPath(base, key string) string {
+	h := hashKey(key)
+	return filepath.Join(base, h[0:2], h[2:4], h)
+}
+
+// sidecarPath returns the path of the small file alongside a data file at
+// dataPath that records the original key, for collision detection and
+// debugging.
+func sidecarPath(dataPath string) string {
+	return dataPath + keySidecarSuffix
+}
+
+// ensureParentDir creates the sharded parent directories for path, if they
+// don't already exist.
+func ensureParentDir(path string) error {
+	return os.MkdirAll(filepath.Dir(path), 0755)
+}
+
+// migrateOldLayout moves a pre-sharding entry (stored flat at
+// base+"/"+key) into the new sharded layout, if one exists. It is a no-op
+// if the key was never stored under the old flat layout.
+func migrateOldLayout(base, key string) error {
+	oldPath := filepath.Join(base, key)
+
+	info, err := os.Stat(oldPath)
+	if os.IsNotExist(err) || (err == nil && info.IsDir()) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	newPath := shardedPath(base, key)
+	if err := ensureParentDir(newPath); err != nil {
+		return err
+	}
+
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return err
+	}
+
+	return os.WriteFile(sidecarPath(newPath), []byte(key), 0644)
+}
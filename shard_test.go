@@ -0,0 +1,82 @@
+package bytecacher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestShardedPathNestsByHashPrefix(t *testing.T) {
+	p := shardedPath("/root", "some/key")
+	h := hashKey("some/key")
+
+	want := filepath.Join("/root", h[0:2], h[2:4], h)
+	if p != want {
+		t.Errorf("shardedPath = %q, want %q", p, want)
+	}
+}
+
+func TestGetHandlesKeysWithSlashesAndUnsafeChars(t *testing.T) {
+	dir := t.TempDir()
+
+	c := NewCacher(func(key string) ([]byte, error) {
+		return []byte("value-for-" + key), nil
+	}, dir)
+
+	for _, key := range []string{"a/b/c", "../../etc/passwd", "with spaces", ""} {
+		ans, err := c.Get(key)
+		if err != nil {
+			t.Fatalf("Get(%q): %v", key, err)
+		}
+		if want := "value-for-" + key; string(ans) != want {
+			t.Errorf("Get(%q) = %q, want %q", key, ans, want)
+		}
+	}
+}
+
+func TestPutWritesKeySidecar(t *testing.T) {
+	dir := t.TempDir()
+
+	c := NewCacher(func(key string) ([]byte, error) {
+		return []byte("v"), nil
+	}, dir)
+
+	if _, err := c.Get("my-key"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	sidecar := sidecarPath(shardedPath(dir, "my-key"))
+	got, err := os.ReadFile(sidecar)
+	if err != nil {
+		t.Fatalf("reading sidecar: %v", err)
+	}
+	if string(got) != "my-key" {
+		t.Errorf("sidecar contents = %q, want %q", got, "my-key")
+	}
+}
+
+func TestMigrateOldLayoutMovesFlatFileIntoShardedLocation(t *testing.T) {
+	dir := t.TempDir()
+
+	oldPath := filepath.Join(dir, "legacy-key")
+	if err := os.WriteFile(oldPath, []byte("legacy-value"), 0644); err != nil {
+		t.Fatalf("seeding old flat-layout file: %v", err)
+	}
+
+	c := NewCacher(func(key string) ([]byte, error) {
+		t.Fatalf("unexpected lookup for %s; should have migrated the flat-layout file", key)
+		return nil, nil
+	}, dir)
+
+	ans, err := c.Get("legacy-key")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if string(ans) != "legacy-value" {
+		t.Errorf("Get = %q, want %q", ans, "legacy-value")
+	}
+
+	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+		t.Errorf("old flat-layout file still exists at %s", oldPath)
+	}
+}
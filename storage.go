@@ -0,0 +1,251 @@
+package bytecacher
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Storage is the backend a Cacher reads and writes cache entries through.
+// NewCacher builds a Cacher over FSStorage; NewCacherWithStorage lets
+// callers layer Cacher's locking/maxAge/singleflight logic over any other
+// blob store.
+type Storage interface {
+	// Get returns the stored payload for key and the time it was last
+	// written. It returns an error if key is not stored.
+	Get(key string) ([]byte, time.Time, error)
+
+	// Put stores data under key, replacing any existing value.
+	Put(key string, data []byte) error
+
+	// Delete removes the entry for key. It is not an error if key is not
+	// stored.
+	Delete(key string) error
+
+	// Walk calls fn once per stored entry, so a Cacher can rebuild
+	// in-memory state (e.g. an LRU heap) after a restart.
+	Walk(fn func(key string, size int64, mtime time.Time)) error
+}
+
+// FSStorage is the default Storage backend: entries are sharded into
+// hashed subdirectories under a root path, written atomically via
+// temp-file-then-rename, with a small sidecar recording each entry's
+// original key.
+type FSStorage struct {
+	path string
+}
+
+// NewFSStorage builds an FSStorage rooted at path, sweeping any stray temp
+// files left behind by a process that crashed mid-write in a previous run.
+func NewFSStorage(path string) *FSStorage {
+	if err := sweepTempFiles(path); err != nil {
+		log.Printf("sweeping stray temp files under %s failed: %v", path, err)
+	}
+
+	return &FSStorage{path: path}
+}
+
+func (s *FSStorage) Get(key string) ([]byte, time.Time, error) {
+	if err := migrateOldLayout(s.path, key); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	path := shardedPath(s.path, key)
+
+	exists, mtime := fileExists(path)
+	if !exists {
+		return nil, time.Time{}, fmt.Errorf("not stored")
+	}
+
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	return dat, mtime, nil
+}
+
+func (s *FSStorage) Put(key string, data []byte) error {
+	path := shardedPath(s.path, key)
+	if err := ensureParentDir(path); err != nil {
+		return err
+	}
+
+	if err := writeFileAtomically(path, data); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(sidecarPath(path), []byte(key), 0644)
+}
+
+func (s *FSStorage) Delete(key string) error {
+	path := shardedPath(s.path, key)
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.Remove(sidecarPath(path)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *FSStorage) Walk(fn func(key string, size int64, mtime time.Time)) error {
+	err := filepath.Walk(s.path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		if info.IsDir() || strings.HasSuffix(p, keySidecarSuffix) {
+			return nil
+		}
+
+		keyBytes, err := os.ReadFile(sidecarPath(p))
+		if err != nil {
+			// No sidecar: not a sharded cache entry (e.g. a stray file
+			// from the pre-sharding flat layout). Leave it for
+			// migrateOldLayout to pick up lazily.
+			return nil
+		}
+
+		fn(string(keyBytes), info.Size(), info.ModTime())
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// writeFileAtomically writes dat to a temp file in path's directory, fsyncs
+// it, and renames it into place so a crash mid-write can never leave a
+// partially-written file at path. The parent directory is fsynced too, so
+// the rename itself is durable on Unix.
+func writeFileAtomically(path string, dat []byte) error {
+	dir := filepath.Dir(path)
+
+	tmp, err := os.CreateTemp(dir, tmpFilePrefix+"*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	if _, err := tmp.Write(dat); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	if err := os.Rename(tmpName, path); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	if dirF, err := os.Open(dir); err == nil {
+		dirF.Sync()
+		dirF.Close()
+	}
+
+	return nil
+}
+
+// fileExists reports if there is a file at the given path or not.
+func fileExists(filePath string) (bool, time.Time) {
+
+	info, err := os.Stat(filePath)
+
+	if os.IsNotExist(err) {
+		return false, time.Time{}
+	}
+
+	if err == nil {
+		if info.IsDir() {
+			return false, time.Time{}
+		}
+
+		return true, info.ModTime()
+	}
+
+	log.Fatalf("encountered unhandled error %+v while statting file %s", err, filePath)
+
+	// never happens
+	return false, time.Time{}
+}
+
+// InMemoryStorage is a Storage backed by a plain map, for tests that don't
+// want to touch the filesystem.
+type InMemoryStorage struct {
+	mu      sync.Mutex
+	entries map[string]inMemoryEntry
+}
+
+type inMemoryEntry struct {
+	data  []byte
+	mtime time.Time
+}
+
+// NewInMemoryStorage builds an empty InMemoryStorage.
+func NewInMemoryStorage() *InMemoryStorage {
+	return &InMemoryStorage{entries: map[string]inMemoryEntry{}}
+}
+
+func (s *InMemoryStorage) Get(key string) ([]byte, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		return nil, time.Time{}, fmt.Errorf("not stored")
+	}
+
+	return e.data, e.mtime, nil
+}
+
+func (s *InMemoryStorage) Put(key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[key] = inMemoryEntry{data: data, mtime: time.Now()}
+	return nil
+}
+
+func (s *InMemoryStorage) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *InMemoryStorage) Walk(fn func(key string, size int64, mtime time.Time)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, e := range s.entries {
+		fn(k, int64(len(e.data)), e.mtime)
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package bytecacher
+
+import (
+	"fmt"
+	"time"
+)
+
+// S3Storage sketches how Storage maps onto an S3-compatible object store:
+// Put would PutObject under Prefix+key, Get would GetObject and read
+// LastModified from the response instead of a filesystem mtime, Delete
+// would DeleteObject, and Walk would paginate ListObjectsV2. Wiring up a
+// real AWS SDK client is left to callers that need it; this type exists to
+// document the shape of an object-store Storage, not to be used directly.
+type S3Storage struct {
+	Bucket string
+	Prefix string
+}
+
+var _ Storage = (*S3Storage)(nil)
+
+func (s *S3Storage) Get(key string) ([]byte, time.Time, error) {
+	return nil, time.Time{}, fmt.Errorf("bytecacher: S3Storage is a sketch, wire up an S3 client")
+}
+
+func (s *S3Storage) Put(key string, data []byte) error {
+	return fmt.Errorf("bytecacher: S3Storage is a sketch, wire up an S3 client")
+}
+
+func (s *S3Storage) Delete(key string) error {
+	return fmt.Errorf("bytecacher: S3Storage is a sketch, wire up an S3 client")
+}
+
+func (s *S3Storage) Walk(fn func(key string, size int64, mtime time.Time)) error {
+	return fmt.Errorf("bytecacher: S3Storage is a sketch, wire up an S3 client")
+}
+
+// GCSStorage sketches the same shape for Google Cloud Storage: Put would
+// write through the storage.Writer API, Get would read an object's
+// Attrs().Updated for mtime, Delete would call Object.Delete, and Walk
+// would page through Bucket.Objects. See S3Storage for the general shape;
+// this type is likewise a sketch, not a working client.
+type GCSStorage struct {
+	Bucket string
+	Prefix string
+}
+
+var _ Storage = (*GCSStorage)(nil)
+
+func (s *GCSStorage) Get(key string) ([]byte, time.Time, error) {
+	return nil, time.Time{}, fmt.Errorf("bytecacher: GCSStorage is a sketch, wire up a GCS client")
+}
+
+func (s *GCSStorage) Put(key string, data []byte) error {
+	return fmt.Errorf("bytecacher: GCSStorage is a sketch, wire up a GCS client")
+}
+
+func (s *GCSStorage) Delete(key string) error {
+	return fmt.Errorf("bytecacher: GCSStorage is a sketch, wire up a GCS client")
+}
+
+func (s *GCSStorage) Walk(fn func(key string, size int64, mtime time.Time)) error {
+	return fmt.Errorf("bytecacher: GCSStorage is a sketch, wire up a GCS client")
+}
@@ -0,0 +1,111 @@
+package bytecacher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFSStorageRoundTrip(t *testing.T) {
+	testStorageRoundTrip(t, NewFSStorage(t.TempDir()))
+}
+
+func TestInMemoryStorageRoundTrip(t *testing.T) {
+	testStorageRoundTrip(t, NewInMemoryStorage())
+}
+
+// testStorageRoundTrip exercises Get/Put/Delete/Walk against any Storage
+// implementation, so FSStorage and InMemoryStorage are held to the same
+// contract.
+func testStorageRoundTrip(t *testing.T, s Storage) {
+	t.Helper()
+
+	if _, _, err := s.Get("missing"); err == nil {
+		t.Error("Get on an unstored key returned no error")
+	}
+
+	before := time.Now()
+	if err := s.Put("k", []byte("v1")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	dat, mtime, err := s.Get("k")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if string(dat) != "v1" {
+		t.Errorf("Get = %q, want %q", dat, "v1")
+	}
+	if mtime.Before(before) {
+		t.Errorf("Get mtime = %v, want >= %v", mtime, before)
+	}
+
+	if err := s.Put("k", []byte("v2")); err != nil {
+		t.Fatalf("Put (overwrite): %v", err)
+	}
+	if dat, _, err := s.Get("k"); err != nil || string(dat) != "v2" {
+		t.Errorf("Get after overwrite = %q, %v, want %q, nil", dat, err, "v2")
+	}
+
+	if err := s.Delete("missing"); err != nil {
+		t.Errorf("Delete on an unstored key returned an error: %v", err)
+	}
+
+	if err := s.Delete("k"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, _, err := s.Get("k"); err == nil {
+		t.Error("Get after Delete returned no error")
+	}
+
+	want := map[string]string{"a": "1", "b": "22", "c": "333"}
+	for key, val := range want {
+		if err := s.Put(key, []byte(val)); err != nil {
+			t.Fatalf("Put(%s): %v", key, err)
+		}
+	}
+
+	seen := map[string]int64{}
+	if err := s.Walk(func(key string, size int64, mtime time.Time) {
+		seen[key] = size
+	}); err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+
+	if len(seen) != len(want) {
+		t.Fatalf("Walk saw %d entries, want %d", len(seen), len(want))
+	}
+	for key, val := range want {
+		if size, ok := seen[key]; !ok {
+			t.Errorf("Walk did not report %s", key)
+		} else if size != int64(len(val)) {
+			t.Errorf("Walk size for %s = %d, want %d", key, size, len(val))
+		}
+	}
+}
+
+func TestCacherOverInMemoryStorage(t *testing.T) {
+	var calls int
+	c := NewCacherWithStorage(func(key string) ([]byte, error) {
+		calls++
+		return []byte("value-for-" + key), nil
+	}, NewInMemoryStorage())
+
+	ans, err := c.Get("k")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if string(ans) != "value-for-k" {
+		t.Errorf("Get = %q, want %q", ans, "value-for-k")
+	}
+
+	ans2, err := c.Get("k")
+	if err != nil {
+		t.Fatalf("Get (hit): %v", err)
+	}
+	if string(ans2) != "value-for-k" {
+		t.Errorf("Get (hit) = %q, want %q", ans2, "value-for-k")
+	}
+	if calls != 1 {
+		t.Errorf("lookup calls = %d, want 1 (second Get should hit the cache)", calls)
+	}
+}
@@ -0,0 +1,154 @@
+package bytecacher
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// NewStreamingCacher builds a Cacher whose lookups and cache reads happen
+// through io.ReadCloser rather than buffering the whole payload in memory,
+// for workloads like audio/video where a full []byte copy is prohibitive.
+// Use GetReader instead of Get/GetMaxAge with a Cacher built this way.
+func NewStreamingCacher(
+	lookupFn func(string) (io.ReadCloser, error),
+	path string,
+) *Cacher {
+	c := NewCacher(nil, path)
+	c.streamLookup = lookupFn
+	return c
+}
+
+// GetReader returns a reader over the cached payload for key, looking it up
+// via the streaming lookup function on a miss. On a miss the upstream
+// reader is teed into a temp file as the caller reads, so consumption can
+// start before the entry has finished being written to disk.
+//
+// GetReader is only meaningful on a Cacher built with NewStreamingCacher; it
+// returns an error if streamLookup was never set. It also returns an error
+// if VerifyIntegrity is set, since the streamed write/read path hands bytes
+// to the caller as they arrive and has no point at which to check or strip
+// a trailer computed over the whole payload.
+func (c *Cacher) GetReader(key string) (io.ReadCloser, error) {
+	c.debug("checking for %s in cache", key)
+
+	if c.streamLookup == nil {
+		return nil, fmt.Errorf("GetReader: Cacher was not built with NewStreamingCacher")
+	}
+	if c.VerifyIntegrity {
+		return nil, fmt.Errorf("GetReader: VerifyIntegrity is not supported on the streaming path")
+	}
+
+	mx := c.getMx(key)
+	mx.RLock()
+	if err := migrateOldLayout(c.path, key); err != nil {
+		c.debug("migrating %s to sharded layout failed: %v", key, err)
+	}
+
+	path := shardedPath(c.path, key)
+	if exists, _ := fileExists(path); exists {
+		f, err := os.Open(path)
+		mx.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+
+		c.debug("found!")
+		atomic.AddUint64(&c.hits, 1)
+		if info, statErr := f.Stat(); statErr == nil {
+			c.touch(key, info.Size())
+		}
+		return f, nil
+	}
+	mx.RUnlock()
+
+	atomic.AddUint64(&c.misses, 1)
+	return c.storeStreaming(key)
+}
+
+// storeStreaming looks up key via the streaming lookup function and tees
+// the result into a temp file while handing the caller a reader over the
+// same bytes, so the cache entry and the caller's consumption proceed
+// concurrently. On success the temp file is renamed into place; on error
+// it is removed.
+//
+// getMx(key) is held for the writer's whole lifetime, including the
+// background copy below, not just until the reader is handed back: GetReader
+// checks for an on-disk entry under an RLock, so another caller arriving
+// mid-copy blocks until the copy either lands on disk or fails, instead of
+// seeing no file yet and kicking off its own redundant streamLookup call.
+func (c *Cacher) storeStreaming(key string) (io.ReadCloser, error) {
+	c.debug("cache miss")
+	mx := c.getMx(key)
+	mx.Lock()
+
+	path := shardedPath(c.path, key)
+	if exists, _ := fileExists(path); exists {
+		if f, err := os.Open(path); err == nil {
+			c.debug("coalesced with in-flight store for %s", key)
+			atomic.AddUint64(&c.hits, 1)
+			if info, statErr := f.Stat(); statErr == nil {
+				c.touch(key, info.Size())
+			}
+			mx.Unlock()
+			return f, nil
+		}
+	}
+
+	upstream, err := c.streamLookup(key)
+	if err != nil {
+		mx.Unlock()
+		return nil, err
+	}
+
+	if err := ensureParentDir(path); err != nil {
+		upstream.Close()
+		mx.Unlock()
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), tmpFilePrefix+"*")
+	if err != nil {
+		upstream.Close()
+		mx.Unlock()
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	mw := io.MultiWriter(tmp, pw)
+
+	go func() {
+		defer mx.Unlock()
+		defer upstream.Close()
+
+		_, copyErr := io.Copy(mw, upstream)
+		if closeErr := tmp.Close(); copyErr == nil {
+			copyErr = closeErr
+		}
+		if copyErr != nil {
+			os.Remove(tmp.Name())
+			pw.CloseWithError(copyErr)
+			return
+		}
+
+		if err := os.Rename(tmp.Name(), path); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+
+		if err := os.WriteFile(sidecarPath(path), []byte(key), 0644); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+
+		if info, err := os.Stat(path); err == nil {
+			c.touch(key, info.Size())
+		}
+
+		pw.Close()
+	}()
+
+	return pr, nil
+}
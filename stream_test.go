@@ -0,0 +1,86 @@
+package bytecacher
+
+import (
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGetReaderMissThenHit(t *testing.T) {
+	dir := t.TempDir()
+
+	var calls int64
+	c := NewStreamingCacher(func(key string) (io.ReadCloser, error) {
+		atomic.AddInt64(&calls, 1)
+		return io.NopCloser(strings.NewReader("payload-" + key)), nil
+	}, dir)
+
+	r, err := c.GetReader("a/b")
+	if err != nil {
+		t.Fatalf("GetReader (miss): %v", err)
+	}
+	got, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatalf("reading miss result: %v", err)
+	}
+	if string(got) != "payload-a/b" {
+		t.Errorf("miss payload = %q, want %q", got, "payload-a/b")
+	}
+
+	r2, err := c.GetReader("a/b")
+	if err != nil {
+		t.Fatalf("GetReader (hit): %v", err)
+	}
+	got2, err := io.ReadAll(r2)
+	r2.Close()
+	if err != nil {
+		t.Fatalf("reading hit result: %v", err)
+	}
+	if string(got2) != "payload-a/b" {
+		t.Errorf("hit payload = %q, want %q", got2, "payload-a/b")
+	}
+
+	if calls != 1 {
+		t.Errorf("streamLookup calls = %d, want 1", calls)
+	}
+}
+
+func TestGetReaderPropagatesUpstreamError(t *testing.T) {
+	dir := t.TempDir()
+
+	wantErr := io.ErrUnexpectedEOF
+	c := NewStreamingCacher(func(key string) (io.ReadCloser, error) {
+		return nil, wantErr
+	}, dir)
+
+	if _, err := c.GetReader("k"); err != wantErr {
+		t.Errorf("GetReader error = %v, want %v", err, wantErr)
+	}
+}
+
+func TestGetReaderOnNonStreamingCacherReturnsError(t *testing.T) {
+	dir := t.TempDir()
+
+	c := NewCacher(func(key string) ([]byte, error) {
+		return []byte("v"), nil
+	}, dir)
+
+	if _, err := c.GetReader("k"); err == nil {
+		t.Error("GetReader on a Cacher built with NewCacher returned no error, want one (streamLookup is nil)")
+	}
+}
+
+func TestGetReaderRejectsVerifyIntegrity(t *testing.T) {
+	dir := t.TempDir()
+
+	c := NewStreamingCacher(func(key string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("v")), nil
+	}, dir)
+	c.VerifyIntegrity = true
+
+	if _, err := c.GetReader("k"); err == nil {
+		t.Error("GetReader with VerifyIntegrity set returned no error, want one")
+	}
+}